@@ -21,7 +21,9 @@
 package nelmead
 
 import (
+	"math"
 	"sort"
+	"sync"
 )
 
 // NelderMeadOptimizer is a (tiny) Go implementation of the
@@ -43,6 +45,242 @@ type NelderMeadOptimizer struct {
 	dim       int
 	best      float64
 	bestPrev  float64
+
+	// Lower and Upper are optional box bounds on x (lower[i] <= x[i] <= upper[i]).
+	// A nil slice, or a -Inf/+Inf entry, leaves the corresponding side
+	// unbounded. Set via MakeBoundedNelderMeadOptimizer.
+	Lower, Upper []float64
+	// Gs is an optional set of nonlinear inequality constraints, each
+	// interpreted as g(x) >= 0. Set via MakeBoundedNelderMeadOptimizer.
+	Gs []func([]float64) float64
+	// mu is the weight of the adaptive quadratic exterior penalty used to
+	// steer infeasible trial points back towards the region where all Gs
+	// are satisfied. It grows across outer iterations whenever the current
+	// best vertex is still infeasible.
+	mu float64
+	// infeasibleCount counts consecutive iterations in which none of the
+	// reflection/expansion/contraction candidates were feasible.
+	infeasibleCount int
+
+	// Adaptive, when true, makes Optimize recompute Alpha/Gamma/Rho/Sigma
+	// from nmo.dim at entry using the Gao-Han scheme, instead of using the
+	// fixed 1, 2, 0.5, 0.5 constants. Set via MakeAdaptiveNelderMeadOptimizer.
+	Adaptive bool
+
+	// Initializer, when set, is the default SimplexInitializer used by
+	// Optimize to build the initial simplex around start. A nil Initializer
+	// falls back to AxisSimplex{Step: 1.0}.
+	Initializer SimplexInitializer
+
+	// RestartOnStall enables Kelley's (1999) oriented restart: instead of
+	// terminating the first time CheckTerminate reports a stall, the
+	// simplex is rebuilt around the best vertex with edges oriented along
+	// the approximate descent direction, and the search continues.
+	RestartOnStall bool
+	// MaxRestarts bounds the number of oriented restarts performed; once it
+	// is reached, the next stall terminates Optimize and reports convergence
+	// as usual.
+	MaxRestarts  int
+	restartCount int
+	restartStep  float64
+
+	// Observer, when set, is invoked by Optimize after every accept/reject
+	// decision with the iteration count, the kind of step taken, the
+	// simplex as it stands after the decision, the centroid used (nil for
+	// a StepKind that does not compute one), the trial point considered
+	// (nil where there is none, e.g. Initialize/Terminate), and that
+	// trial's score. Use it to log or plot the simplex trajectory, or to
+	// drive early stopping from a closure; see TraceRecorder for a ready-
+	// made implementation that records snapshots instead.
+	Observer func(iter int, kind StepKind, spx SimplexValues, centroid []float64, trial []float64, trialScore float64)
+
+	// Evaluator, when set, batch-evaluates the n simultaneous points of a
+	// shrink step (and the initial simplex) instead of scoring them one at
+	// a time. A nil Evaluator scores points serially via nmo.score.
+	Evaluator Evaluator
+}
+
+// Evaluator computes the score of a batch of points, in the same order
+// they were given in, so callers can rely on the result lining up with the
+// input regardless of how (or whether) the evaluation was parallelized.
+type Evaluator interface {
+	Evaluate(points [][]float64) []float64
+}
+
+// ParallelEvaluator fans a batch of points out across a fixed pool of
+// Workers goroutines, turning an O(n) serial shrink step into an O(n/Workers)
+// wall-clock step for expensive objective functions. F is filled in
+// automatically with the owning NelderMeadOptimizer's scoring function
+// (objective plus any constraint penalty) unless already set; user-supplied
+// f (and every Gs) must be safe for concurrent invocation when a
+// ParallelEvaluator is used. Results are written back by index, so vertex
+// order is preserved regardless of goroutine scheduling.
+type ParallelEvaluator struct {
+	F       func([]float64) float64
+	Workers int
+}
+
+func (pe *ParallelEvaluator) Evaluate(points [][]float64) []float64 {
+	workers := pe.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(points) {
+		workers = len(points)
+	}
+
+	scores := make([]float64, len(points))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				scores[i] = pe.F(points[i])
+			}
+		}()
+	}
+	for i := range points {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return scores
+}
+
+// evalBatch scores every point in points, using nmo.Evaluator when set (and
+// wiring it to nmo.score first, if it is a *ParallelEvaluator without its
+// own F) or nmo.score one at a time otherwise.
+func (nmo *NelderMeadOptimizer) evalBatch(points [][]float64) []float64 {
+	if nmo.Evaluator == nil {
+		scores := make([]float64, len(points))
+		for i, p := range points {
+			scores[i] = nmo.score(p)
+		}
+		return scores
+	}
+	if pe, ok := nmo.Evaluator.(*ParallelEvaluator); ok && pe.F == nil {
+		pe.F = nmo.score
+	}
+	return nmo.Evaluator.Evaluate(points)
+}
+
+// shrinkTowardBest performs step (6) of the algorithm, replacing every
+// vertex but the best with spx[0] + Sigma*(spx[i]-spx[0]), scoring the batch
+// through evalBatch so a configured Evaluator can fan the n simultaneous
+// calls out across goroutines.
+func (nmo *NelderMeadOptimizer) shrinkTowardBest(spx SimplexValues) {
+	xs := make([][]float64, nmo.dim)
+	for i := 1; i <= nmo.dim; i++ {
+		xs[i-1] = nmo.clip(MakePoint(nmo.Sigma, spx[0].X, spx[i].X))
+	}
+	scores := nmo.evalBatch(xs)
+	for i := 1; i <= nmo.dim; i++ {
+		spx.Set(i, xs[i-1], scores[i-1])
+	}
+}
+
+// StepKind identifies which branch of the Nelder-Mead iteration an Observer
+// callback is being notified about, mirroring gonum's nmIterType.
+type StepKind int
+
+const (
+	Initialize StepKind = iota
+	Reflect
+	Expand
+	ContractOutside
+	ContractInside
+	Shrink
+	// Restart reports a Kelley oriented restart (see restart): the whole
+	// simplex, bar the anchored best vertex, was just rebuilt, so any
+	// jump in spread it causes must not be attributed to the next
+	// ordinary step.
+	Restart
+	Terminate
+)
+
+func (k StepKind) String() string {
+	switch k {
+	case Initialize:
+		return "Initialize"
+	case Reflect:
+		return "Reflect"
+	case Expand:
+		return "Expand"
+	case ContractOutside:
+		return "ContractOutside"
+	case ContractInside:
+		return "ContractInside"
+	case Shrink:
+		return "Shrink"
+	case Restart:
+		return "Restart"
+	case Terminate:
+		return "Terminate"
+	default:
+		return "Unknown"
+	}
+}
+
+// observe invokes nmo.Observer, if set.
+func (nmo *NelderMeadOptimizer) observe(iter int, kind StepKind, spx SimplexValues, centroid, trial []float64, trialScore float64) {
+	if nmo.Observer != nil {
+		nmo.Observer(iter, kind, spx, centroid, trial, trialScore)
+	}
+}
+
+// TraceSnapshot is one deep-copied observation captured by a TraceRecorder.
+type TraceSnapshot struct {
+	Iter       int
+	Kind       StepKind
+	Simplex    SimplexValues
+	Centroid   []float64
+	Trial      []float64
+	TrialScore float64
+}
+
+// TraceRecorder is a ready-made NelderMeadOptimizer.Observer implementation
+// that deep-copies every callback into a TraceSnapshot, for post-hoc
+// analysis of the simplex trajectory or for use in tests. Assign its Record
+// method to Observer:
+//
+//	tr := &TraceRecorder{}
+//	nmo.Observer = tr.Record
+type TraceRecorder struct {
+	Snapshots []TraceSnapshot
+}
+
+// Record deep-copies its arguments into a new TraceSnapshot and appends it
+// to tr.Snapshots.
+func (tr *TraceRecorder) Record(iter int, kind StepKind, spx SimplexValues, centroid, trial []float64, trialScore float64) {
+	tr.Snapshots = append(tr.Snapshots, TraceSnapshot{
+		Iter:       iter,
+		Kind:       kind,
+		Simplex:    copySimplex(spx),
+		Centroid:   copyFloats(centroid),
+		Trial:      copyFloats(trial),
+		TrialScore: trialScore,
+	})
+}
+
+// copyFloats returns a deep copy of x, preserving a nil slice as nil.
+func copyFloats(x []float64) []float64 {
+	if x == nil {
+		return nil
+	}
+	v := make([]float64, len(x))
+	copy(v, x)
+	return v
+}
+
+// copySimplex returns a deep copy of spx.
+func copySimplex(spx SimplexValues) SimplexValues {
+	v := make(SimplexValues, len(spx))
+	for i, vs := range spx {
+		v[i] = VertexScore{X: copyFloats(vs.X), Score: vs.Score}
+	}
+	return v
 }
 
 func MakeNelderMeadOptimizer(f func([]float64) float64, dim int) *NelderMeadOptimizer {
@@ -59,6 +297,51 @@ func MakeNelderMeadOptimizer(f func([]float64) float64, dim int) *NelderMeadOpti
 
 }
 
+// MakeBoundedNelderMeadOptimizer creates a NelderMeadOptimizer for problems
+// with box bounds (lower[i] <= x[i] <= upper[i], either slice may be nil to
+// leave that side unbounded) and a set of nonlinear inequality constraints
+// gs, each interpreted as g(x) >= 0. Box violations are clipped back onto
+// the boundary; constraint violations are driven to zero by an adaptive
+// quadratic exterior penalty, following the problem class supported by the
+// Scilab/R neldermead toolbox.
+func MakeBoundedNelderMeadOptimizer(f func([]float64) float64, dim int, lower, upper []float64, gs []func([]float64) float64) *NelderMeadOptimizer {
+	nmo := MakeNelderMeadOptimizer(f, dim)
+	nmo.Lower = lower
+	nmo.Upper = upper
+	nmo.Gs = gs
+	nmo.mu = 1.0
+	return nmo
+}
+
+// MakeAdaptiveNelderMeadOptimizer creates a NelderMeadOptimizer with the
+// Adaptive flag set, so that Optimize derives Alpha/Gamma/Rho/Sigma from dim
+// using the Gao-Han adaptive scheme (see setAdaptiveParameters) rather than
+// the standard 1, 2, 0.5, 0.5 constants. This is known to significantly
+// improve convergence for dim above roughly 10, where the standard
+// constants degrade.
+func MakeAdaptiveNelderMeadOptimizer(f func([]float64) float64, dim int) *NelderMeadOptimizer {
+	nmo := MakeNelderMeadOptimizer(f, dim)
+	nmo.Adaptive = true
+	nmo.setAdaptiveParameters()
+	return nmo
+}
+
+// setAdaptiveParameters recomputes Alpha/Gamma/Rho/Sigma from nmo.dim using
+// the Gao-Han adaptive scheme (Gao and Han, "Implementing the Nelder-Mead
+// simplex algorithm with adaptive parameters", 2012):
+//
+//	Alpha = 1
+//	Gamma = 1 + 2/n
+//	Rho   = 0.75 - 1/(2n)
+//	Sigma = 1 - 1/n
+func (nmo *NelderMeadOptimizer) setAdaptiveParameters() {
+	n := float64(nmo.dim)
+	nmo.Alpha = 1.0
+	nmo.Gamma = 1.0 + 2.0/n
+	nmo.Rho = 0.75 - 1.0/(2.0*n)
+	nmo.Sigma = 1.0 - 1.0/n
+}
+
 type VertexScore struct {
 	X     []float64
 	Score float64
@@ -81,6 +364,171 @@ func MakeSimplexValues(start []float64, step float64) SimplexValues {
 	return spx
 }
 
+// MakeBoundedSimplexValues builds the initial simplex like MakeSimplexValues,
+// but projects every vertex back inside the [lower, upper] box. If stepping
+// out along a coordinate would leave the box, that vertex steps the other
+// way instead (i.e. the step is negated) when that fits, and otherwise the
+// step is shrunk to the room left on the roomier side (see boundedStep), so
+// the simplex is kept inside the box rather than flattened against the
+// boundary.
+func MakeBoundedSimplexValues(start []float64, step float64, lower, upper []float64) SimplexValues {
+	dim := len(start)
+	spx := make([]VertexScore, dim+1)
+	spx[0].X = clipToBox(start, lower, upper)
+
+	for i := 1; i <= dim; i++ {
+		v := make([]float64, dim)
+		copy(v, spx[0].X)
+		v[i-1] += boundedStep(v[i-1], step, lower, upper, i-1)
+		spx[i].X = clipToBox(v, lower, upper)
+	}
+	return spx
+}
+
+// boundedStep returns the step to take along coordinate j from x (already
+// inside the box): step itself if there is room to take it, the same
+// magnitude in the opposite direction if that fits instead, and otherwise
+// the step shrunk to whatever room remains on the roomier side. step may be
+// negative. clipToBox is still applied by the caller as a backstop, but
+// should never need to move the result in practice.
+func boundedStep(x, step float64, lower, upper []float64, j int) float64 {
+	sign, mag := 1.0, step
+	if step < 0 {
+		sign, mag = -1.0, -step
+	}
+	roomFwd, roomBack := math.Inf(1), math.Inf(1)
+	if sign > 0 {
+		if upper != nil {
+			roomFwd = upper[j] - x
+		}
+		if lower != nil {
+			roomBack = x - lower[j]
+		}
+	} else {
+		if lower != nil {
+			roomFwd = x - lower[j]
+		}
+		if upper != nil {
+			roomBack = upper[j] - x
+		}
+	}
+	switch {
+	case mag <= roomFwd:
+		return step
+	case mag <= roomBack:
+		return -step
+	case roomFwd >= roomBack:
+		return sign * roomFwd
+	default:
+		return -sign * roomBack
+	}
+}
+
+// boundedVertex adjusts v, coordinate by coordinate, so every displacement
+// from base fits inside [lower, upper] using boundedStep — shrinking or
+// flipping each displacement rather than flattening it against the
+// boundary. Unlike MakeBoundedSimplexValues (which only knows about
+// AxisSimplex's single perturbed coordinate per vertex), this works for any
+// SimplexInitializer's output, including ones like SpendleyRegularSimplex
+// that move every coordinate of every vertex.
+func boundedVertex(base, v, lower, upper []float64) []float64 {
+	result := make([]float64, len(v))
+	copy(result, base)
+	for j := range v {
+		if d := v[j] - base[j]; d != 0 {
+			result[j] += boundedStep(base[j], d, lower, upper, j)
+		}
+	}
+	return result
+}
+
+// clipToBox returns a copy of x with every coordinate clamped into
+// [lower[i], upper[i]]. Either slice may be nil to leave that side
+// unbounded.
+func clipToBox(x []float64, lower, upper []float64) []float64 {
+	v := make([]float64, len(x))
+	copy(v, x)
+	for i := range v {
+		if lower != nil && v[i] < lower[i] {
+			v[i] = lower[i]
+		}
+		if upper != nil && v[i] > upper[i] {
+			v[i] = upper[i]
+		}
+	}
+	return v
+}
+
+// SimplexInitializer builds the dim+1 initial vertices of the simplex
+// around start (scores are left unset; Optimize fills them in).
+type SimplexInitializer interface {
+	Build(start []float64) SimplexValues
+}
+
+// AxisSimplex builds the initial simplex by perturbing each coordinate in
+// turn by Step, the axis-aligned behavior MakeSimplexValues has always
+// provided.
+type AxisSimplex struct {
+	Step float64
+}
+
+func (a AxisSimplex) Build(start []float64) SimplexValues {
+	return MakeSimplexValues(start, a.Step)
+}
+
+// PfefferSimplex builds the initial simplex the way SciPy's fmin does:
+// coordinate i of vertex i+1 is perturbed by DeltaU*x[i] if x[i] is
+// nonzero, and by DeltaZ otherwise.
+type PfefferSimplex struct {
+	DeltaU, DeltaZ float64
+}
+
+func (p PfefferSimplex) Build(start []float64) SimplexValues {
+	dim := len(start)
+	spx := make([]VertexScore, dim+1)
+	spx[0].X = start
+	for i := 1; i <= dim; i++ {
+		v := make([]float64, dim)
+		copy(v, start)
+		if v[i-1] != 0 {
+			v[i-1] += p.DeltaU * v[i-1]
+		} else {
+			v[i-1] += p.DeltaZ
+		}
+		spx[i].X = v
+	}
+	return spx
+}
+
+// SpendleyRegularSimplex builds a regular (equilateral) initial simplex with
+// edge length Edge, using the closed-form vertex positions of Spendley,
+// Hext and Himsworth (1962).
+type SpendleyRegularSimplex struct {
+	Edge float64
+}
+
+func (s SpendleyRegularSimplex) Build(start []float64) SimplexValues {
+	dim := len(start)
+	n := float64(dim)
+	p := s.Edge * (math.Sqrt(n+1) - 1 + n) / (n * math.Sqrt2)
+	q := s.Edge * (math.Sqrt(n+1) - 1) / (n * math.Sqrt2)
+
+	spx := make([]VertexScore, dim+1)
+	spx[0].X = start
+	for i := 1; i <= dim; i++ {
+		v := make([]float64, dim)
+		for j := 0; j < dim; j++ {
+			if j == i-1 {
+				v[j] = start[j] + p
+			} else {
+				v[j] = start[j] + q
+			}
+		}
+		spx[i].X = v
+	}
+	return spx
+}
+
 // SimplexValues.Centroid calculates the centroid of all vertices of the simplex,
 // except the last vertex given by spx[dim]
 func (spx SimplexValues) Centroid() []float64 {
@@ -129,6 +577,158 @@ func (nmo *NelderMeadOptimizer) CheckTerminate() bool {
 
 }
 
+// averageEdgeLength returns the average Euclidean distance from spx[0] to
+// the other vertices of the simplex, used to size the first oriented
+// restart when no explicit step is known.
+func averageEdgeLength(spx SimplexValues) float64 {
+	n := len(spx) - 1
+	var sum float64
+	for i := 1; i <= n; i++ {
+		var d float64
+		for j := 0; j < n; j++ {
+			diff := spx[i].X[j] - spx[0].X[j]
+			d += diff * diff
+		}
+		sum += math.Sqrt(d)
+	}
+	return sum / float64(n)
+}
+
+// solveLinearSystem solves the square system a*x = b with Gaussian
+// elimination and partial pivoting. It reports ok=false, leaving x
+// undefined, when a is (near) singular.
+func solveLinearSystem(a [][]float64, b []float64) (x []float64, ok bool) {
+	n := len(b)
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = append([]float64(nil), a[i]...)
+	}
+	rhs := append([]float64(nil), b...)
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1E-14 {
+			return nil, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		rhs[col], rhs[pivot] = rhs[pivot], rhs[col]
+
+		for row := col + 1; row < n; row++ {
+			factor := m[row][col] / m[col][col]
+			for k := col; k < n; k++ {
+				m[row][k] -= factor * m[col][k]
+			}
+			rhs[row] -= factor * rhs[col]
+		}
+	}
+
+	x = make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := rhs[row]
+		for k := row + 1; k < n; k++ {
+			sum -= m[row][k] * x[k]
+		}
+		x[row] = sum / m[row][row]
+	}
+	return x, true
+}
+
+// restart rebuilds the simplex around the current best vertex spx[0], using
+// an oriented simplex whose edges point along the approximate descent
+// direction (Kelley, 1999). The descent direction is -g, where g is the
+// gradient fitted, via the linear model f(x_i) ~= f(x_0) + g.(x_i - x_0), to
+// the n current edge vectors and their function-value differences; vertex
+// i+1 becomes x_0 - step*e_i where g_i > 0 and x_0 + step*e_i otherwise
+// (including g_i == 0, and every i when the fit is singular), clipped into
+// the box.
+func (nmo *NelderMeadOptimizer) restart(spx SimplexValues, step float64) {
+	n := nmo.dim
+	a := make([][]float64, n)
+	b := make([]float64, n)
+	for i := 0; i < n; i++ {
+		edge := make([]float64, n)
+		for j := 0; j < n; j++ {
+			edge[j] = spx[i+1].X[j] - spx[0].X[j]
+		}
+		a[i] = edge
+		b[i] = spx[i+1].Score - spx[0].Score
+	}
+	g, ok := solveLinearSystem(a, b)
+	if !ok {
+		g = make([]float64, n)
+	}
+
+	for i := 1; i <= n; i++ {
+		v := make([]float64, n)
+		copy(v, spx[0].X)
+		if g[i-1] > 0 {
+			v[i-1] -= step
+		} else {
+			v[i-1] += step
+		}
+		v = nmo.clip(v)
+		spx.Set(i, v, nmo.score(v))
+	}
+}
+
+// Feasible reports whether x lies within the Lower/Upper box and satisfies
+// every constraint in Gs (g(x) >= 0). An optimizer with no bounds and no
+// constraints considers every x feasible.
+func (nmo *NelderMeadOptimizer) Feasible(x []float64) bool {
+	for i, v := range x {
+		if nmo.Lower != nil && v < nmo.Lower[i] {
+			return false
+		}
+		if nmo.Upper != nil && v > nmo.Upper[i] {
+			return false
+		}
+	}
+	for _, g := range nmo.Gs {
+		if g(x) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Violation returns the total squared shortfall of x against the Gs
+// constraints: zero if x satisfies all of them, otherwise the sum of
+// (-g_j(x))^2 over every violated g_j. Box bounds are not included, since
+// they are enforced by clipping rather than penalized.
+func (nmo *NelderMeadOptimizer) Violation(x []float64) float64 {
+	var v float64
+	for _, g := range nmo.Gs {
+		if gv := g(x); gv < 0 {
+			v += gv * gv
+		}
+	}
+	return v
+}
+
+// clip projects x back into the Lower/Upper box, if one is configured.
+func (nmo *NelderMeadOptimizer) clip(x []float64) []float64 {
+	if nmo.Lower == nil && nmo.Upper == nil {
+		return x
+	}
+	return clipToBox(x, nmo.Lower, nmo.Upper)
+}
+
+// score evaluates f at x and, when Gs is set, adds the adaptive exterior
+// penalty mu * Violation(x) so that the simplex is steered away from
+// infeasible candidates without ever having to reject them outright.
+func (nmo *NelderMeadOptimizer) score(x []float64) float64 {
+	s := nmo.f(x)
+	if nmo.Gs != nil {
+		s += nmo.mu * nmo.Violation(x)
+	}
+	return s
+}
+
 // MakePoint creates a new point, using a vector between two points. This is a
 // helper method, to simplify the generation of the new points in the different
 // stages of the optimization process.
@@ -140,16 +740,76 @@ func MakePoint(factor float64, x0, xm []float64) []float64 {
 	return xr
 }
 
+// penaltyGrowth is the factor by which the exterior penalty weight mu grows,
+// per outer iteration, while the best vertex remains infeasible.
+const penaltyGrowth = 10.0
+
+// maxMu caps the exterior penalty weight mu. Without a cap, mu compounds by
+// penaltyGrowth every iteration the best vertex stays infeasible and
+// eventually overflows to +Inf; once mu is +Inf, score(x) evaluates
+// mu*Violation(x) as Inf*0 = NaN for any x that exactly satisfies every Gs,
+// which poisons Sort/CheckTerminate and can make Optimize silently report a
+// bogus "converged" result.
+const maxMu = 1E8
+
+// maxInfeasibleSteps is the number of consecutive iterations without a single
+// feasible reflection/expansion/contraction candidate after which the
+// simplex is forced to shrink towards the current best vertex.
+const maxInfeasibleSteps = 5
+
 // Optimize is the key method of this package, and in this method the actual iteration
 // process of the Nelder-Mead algorithm is implemented. For more detailed information see the
 // Wikimedia entry at: https://en.wikipedia.org/wiki/Nelder%E2%80%93Mead_method.
-func (nmo *NelderMeadOptimizer) Optimize(max_iter int, start []float64, step float64) ([]float64, float64, bool) {
+//
+// init selects how the initial simplex around start is built. A nil init
+// falls back to nmo.Initializer, and if that is also nil, to the plain
+// axis-aligned AxisSimplex{Step: 1.0}. OptimizeStep offers the original
+// (max_iter, start, step) signature for the common axis-aligned case.
+//
+// When the optimizer was built with MakeBoundedNelderMeadOptimizer, every
+// trial point is clipped into the Lower/Upper box and Gs violations are
+// handled through an adaptive exterior penalty; the returned violation is
+// the Violation() of the returned point (zero for an unconstrained
+// optimizer, or a feasible result).
+//
+// When RestartOnStall is set, a stall that would normally terminate the
+// search instead triggers an oriented restart (see restart) around the
+// current best vertex, up to MaxRestarts times; the number of restarts
+// actually performed is returned alongside the usual result.
+func (nmo *NelderMeadOptimizer) Optimize(max_iter int, start []float64, init SimplexInitializer) ([]float64, float64, float64, int, bool) {
+	if nmo.Adaptive {
+		nmo.setAdaptiveParameters()
+	}
+	if init == nil {
+		init = nmo.Initializer
+	}
+	if init == nil {
+		init = AxisSimplex{Step: 1.0}
+	}
+	nmo.restartCount = 0
+	nmo.restartStep = 0
+
 	// initializations
-	spx := MakeSimplexValues(start, step)
-	for i := 0; i <= nmo.dim; i++ {
-		spx[i].Score = nmo.f(spx[i].X)
+	spx := init.Build(start)
+	if nmo.Lower != nil || nmo.Upper != nil {
+		spx[0].X = clipToBox(spx[0].X, nmo.Lower, nmo.Upper)
+		for i := 1; i < len(spx); i++ {
+			spx[i].X = clipToBox(boundedVertex(spx[0].X, spx[i].X, nmo.Lower, nmo.Upper), nmo.Lower, nmo.Upper)
+		}
+	}
+	if nmo.Gs != nil && nmo.mu == 0 {
+		nmo.mu = 1.0
+	}
+	initX := make([][]float64, nmo.dim+1)
+	for i := range spx {
+		initX[i] = spx[i].X
+	}
+	initScores := nmo.evalBatch(initX)
+	for i := range spx {
+		spx[i].Score = initScores[i]
 	}
 	nmo.bestPrev = spx[0].Score
+	nmo.observe(-1, Initialize, spx, nil, nil, spx[0].Score)
 
 	for iters := 0; iters < max_iter; iters++ {
 		//
@@ -157,42 +817,93 @@ func (nmo *NelderMeadOptimizer) Optimize(max_iter int, start []float64, step flo
 		spx.Sort()
 		nmo.best = spx[0].Score
 
+		if nmo.Gs != nil && !nmo.Feasible(spx[0].X) && nmo.mu < maxMu {
+			nmo.mu *= penaltyGrowth
+			if nmo.mu > maxMu {
+				nmo.mu = maxMu
+			}
+		}
+
 		// check termination conditions
 		if nmo.CheckTerminate() {
-			return spx[0].X, spx[0].Score, true
+			if nmo.RestartOnStall && nmo.restartCount < nmo.MaxRestarts {
+				if nmo.restartStep == 0 {
+					nmo.restartStep = averageEdgeLength(spx)
+				} else {
+					nmo.restartStep *= 0.5
+				}
+				nmo.restart(spx, nmo.restartStep)
+				nmo.restartCount++
+				nmo.convCount = 0
+				nmo.bestPrev = spx[0].Score
+				nmo.observe(iters, Restart, spx, nil, nil, spx[0].Score)
+				continue
+			}
+			nmo.observe(iters, Terminate, spx, nil, nil, spx[0].Score)
+			return spx[0].X, nmo.f(spx[0].X), nmo.Violation(spx[0].X), nmo.restartCount, true
 		}
 
 		// (2) calculate  centroid for dim points, exclude dim+1
 		xctr := spx.Centroid()
 
 		// (3) Reflection
-		xr := MakePoint(-nmo.Alpha, xctr, spx[nmo.dim].X) // negative alpha to use general MakePoint function
-		rscore := nmo.f(xr)
+		xr := nmo.clip(MakePoint(-nmo.Alpha, xctr, spx[nmo.dim].X)) // negative alpha to use general MakePoint function
+		rscore := nmo.score(xr)
+
+		allInfeasible := nmo.Gs != nil && !nmo.Feasible(xr)
 
 		switch {
+		case nmo.infeasibleCount >= maxInfeasibleSteps: // (c) force a shrink towards the best vertex
+			nmo.shrinkTowardBest(spx)
+			nmo.infeasibleCount = 0
+			nmo.observe(iters, Shrink, spx, xctr, nil, spx[0].Score)
 		case spx[0].Score <= rscore && rscore < spx[nmo.dim-1].Score:
 			spx.Set(nmo.dim, xr, rscore)
+			nmo.observe(iters, Reflect, spx, xctr, xr, rscore)
 		case rscore < spx[0].Score: // step (4) Expansion
-			xe := MakePoint(nmo.Gamma, xctr, xr)
-			escore := nmo.f(xe)
+			xe := nmo.clip(MakePoint(nmo.Gamma, xctr, xr))
+			escore := nmo.score(xe)
+			allInfeasible = allInfeasible && (nmo.Gs != nil && !nmo.Feasible(xe))
 			if escore < rscore {
 				spx.Set(nmo.dim, xe, escore)
+				nmo.observe(iters, Expand, spx, xctr, xe, escore)
 			} else {
 				spx.Set(nmo.dim, xr, rscore)
+				nmo.observe(iters, Reflect, spx, xctr, xr, rscore)
 			}
 		default: // step (5)
-			xc := MakePoint(nmo.Rho, xctr, spx[nmo.dim].X)
-			cscore := nmo.f(xc)
+			xc := nmo.clip(MakePoint(nmo.Rho, xctr, spx[nmo.dim].X))
+			cscore := nmo.score(xc)
+			allInfeasible = allInfeasible && (nmo.Gs != nil && !nmo.Feasible(xc))
+			// xc always lies between the centroid and the worst vertex; no
+			// separate outside contraction point is computed. kind only
+			// records whether the reflected point beat the worst vertex.
+			kind := ContractInside
+			if rscore < spx[nmo.dim].Score {
+				kind = ContractOutside
+			}
 			if cscore < spx[nmo.dim].Score {
 				spx.Set(nmo.dim, xc, cscore)
+				nmo.observe(iters, kind, spx, xctr, xc, cscore)
 			} else { // step (6)
-				for i := 1; i <= nmo.dim; i++ {
-					x := MakePoint(nmo.Sigma, spx[0].X, spx[i].X)
-					spx.Set(i, x, nmo.f(spx[i].X))
-				}
-
+				nmo.shrinkTowardBest(spx)
+				nmo.observe(iters, Shrink, spx, xctr, nil, spx[0].Score)
 			}
 		}
+
+		if allInfeasible {
+			nmo.infeasibleCount++
+		} else {
+			nmo.infeasibleCount = 0
+		}
 	}
-	return spx[0].X, spx[0].Score, false
+	nmo.observe(max_iter, Terminate, spx, nil, nil, spx[0].Score)
+	return spx[0].X, nmo.f(spx[0].X), nmo.Violation(spx[0].X), nmo.restartCount, false
+}
+
+// OptimizeStep is a convenience wrapper around Optimize for the common case
+// of a plain axis-aligned initial simplex, preserving the original
+// (max_iter, start, step) signature.
+func (nmo *NelderMeadOptimizer) OptimizeStep(max_iter int, start []float64, step float64) ([]float64, float64, float64, int, bool) {
+	return nmo.Optimize(max_iter, start, AxisSimplex{Step: step})
 }
@@ -1,6 +1,7 @@
 package nelmead
 
 import (
+	"math"
 	"testing"
 )
 
@@ -23,8 +24,222 @@ func TestNelderMeadOptimizer_Optimize(t *testing.T) {
 	start := []float64{-1.0, 1.0}
 	step := 0.5
 	nm := MakeNelderMeadOptimizer(Rosenbrock, 2)
-	xval, opt, converged := nm.Optimize(200, start, step)
+	xval, opt, _, _, converged := nm.OptimizeStep(200, start, step)
 	if xval[0]-1.0 > tol || xval[1]-1.0 > tol || !converged {
 		t.Errorf("%v %v %v", xval, opt, converged)
 	}
 }
+
+// GeneralRosenbrock is the n-dimensional generalization of Rosenbrock,
+// summing the classic two-variable term over every consecutive pair.
+func GeneralRosenbrock(x []float64) float64 {
+	const a = 1.0
+	const b = 100.0
+	var sum float64
+	for i := 0; i < len(x)-1; i++ {
+		sum += (a-x[i])*(a-x[i]) + b*(x[i+1]-x[i]*x[i])*(x[i+1]-x[i]*x[i])
+	}
+	return sum
+}
+
+func TestNelderMeadOptimizer_OptimizeAdaptive(t *testing.T) {
+	for _, n := range []int{10, 20} {
+		start := make([]float64, n)
+		for i := range start {
+			start[i] = -1.0
+		}
+		nm := MakeAdaptiveNelderMeadOptimizer(GeneralRosenbrock, n)
+		nm.ConvLimit = 200
+		xval, opt, _, _, _ := nm.OptimizeStep(50000, start, 0.5)
+		if opt > 1.0 {
+			t.Errorf("n=%d: expected a small residual, got %v at %v", n, opt, xval)
+		}
+	}
+}
+
+func TestNelderMeadOptimizer_OptimizeInitializers(t *testing.T) {
+	const tol = 1.E-6
+	start := []float64{-1.0, 1.0}
+	inits := []SimplexInitializer{
+		AxisSimplex{Step: 0.5},
+		PfefferSimplex{DeltaU: 0.05, DeltaZ: 0.00025},
+		SpendleyRegularSimplex{Edge: 0.5},
+	}
+	for _, init := range inits {
+		nm := MakeNelderMeadOptimizer(Rosenbrock, 2)
+		xval, _, _, _, converged := nm.Optimize(2000, start, init)
+		if !converged || xval[0]-1.0 > tol || xval[1]-1.0 > tol {
+			t.Errorf("%T: expected convergence to [1 1], got %v (converged=%v)", init, xval, converged)
+		}
+	}
+}
+
+func TestNelderMeadOptimizer_OptimizeRestartOnStall(t *testing.T) {
+	const tol = 1.E-6
+	start := []float64{-1.0, 1.0}
+	step := 0.5
+	nm := MakeNelderMeadOptimizer(Rosenbrock, 2)
+	nm.RestartOnStall = true
+	nm.MaxRestarts = 10
+	xval, _, _, restarts, converged := nm.OptimizeStep(2000, start, step)
+	if !converged || xval[0]-1.0 > tol || xval[1]-1.0 > tol {
+		t.Errorf("expected convergence to [1 1], got %v (converged=%v, restarts=%d)", xval, converged, restarts)
+	}
+}
+
+func TestNelderMeadOptimizer_OptimizeRestartObserved(t *testing.T) {
+	start := []float64{-1.0, 1.0}
+	step := 0.5
+	tr := &TraceRecorder{}
+	nm := MakeNelderMeadOptimizer(Rosenbrock, 2)
+	nm.ConvLimit = 1 // stall immediately so a restart is forced
+	nm.RestartOnStall = true
+	nm.MaxRestarts = 3
+	nm.Observer = tr.Record
+	_, _, _, restarts, _ := nm.OptimizeStep(50, start, step)
+	if restarts == 0 {
+		t.Fatalf("expected at least one restart")
+	}
+	var sawRestart bool
+	for _, s := range tr.Snapshots {
+		if s.Kind == Restart {
+			sawRestart = true
+			break
+		}
+	}
+	if !sawRestart {
+		t.Errorf("expected a Restart snapshot to be recorded, got kinds: %v", kindsOf(tr.Snapshots))
+	}
+}
+
+func kindsOf(snaps []TraceSnapshot) []StepKind {
+	kinds := make([]StepKind, len(snaps))
+	for i, s := range snaps {
+		kinds[i] = s.Kind
+	}
+	return kinds
+}
+
+func TestNelderMeadOptimizer_OptimizeObserver(t *testing.T) {
+	start := []float64{-1.0, 1.0}
+	step := 0.5
+	tr := &TraceRecorder{}
+	nm := MakeNelderMeadOptimizer(Rosenbrock, 2)
+	nm.Observer = tr.Record
+	_, _, _, _, converged := nm.OptimizeStep(200, start, step)
+	if !converged {
+		t.Fatalf("expected convergence")
+	}
+	if len(tr.Snapshots) < 2 {
+		t.Fatalf("expected at least an Initialize and a Terminate snapshot, got %d", len(tr.Snapshots))
+	}
+	if tr.Snapshots[0].Kind != Initialize {
+		t.Errorf("expected first snapshot to be Initialize, got %v", tr.Snapshots[0].Kind)
+	}
+	last := tr.Snapshots[len(tr.Snapshots)-1]
+	if last.Kind != Terminate {
+		t.Errorf("expected last snapshot to be Terminate, got %v", last.Kind)
+	}
+}
+
+func TestNelderMeadOptimizer_OptimizeParallelEvaluator(t *testing.T) {
+	const tol = 1.E-6
+	start := []float64{-1.0, 1.0}
+	step := 0.5
+	nm := MakeNelderMeadOptimizer(Rosenbrock, 2)
+	nm.Evaluator = &ParallelEvaluator{Workers: 4}
+	xval, _, _, _, converged := nm.OptimizeStep(200, start, step)
+	if !converged || xval[0]-1.0 > tol || xval[1]-1.0 > tol {
+		t.Errorf("expected convergence to [1 1], got %v (converged=%v)", xval, converged)
+	}
+}
+
+func TestParallelEvaluator_Evaluate(t *testing.T) {
+	pe := &ParallelEvaluator{
+		F:       func(x []float64) float64 { return x[0] },
+		Workers: 3,
+	}
+	points := [][]float64{{1}, {2}, {3}, {4}, {5}}
+	scores := pe.Evaluate(points)
+	for i, want := range []float64{1, 2, 3, 4, 5} {
+		if scores[i] != want {
+			t.Errorf("scores[%d] = %v, want %v", i, scores[i], want)
+		}
+	}
+}
+
+func TestNelderMeadOptimizer_OptimizeBounded(t *testing.T) {
+	const tol = 0.05
+	start := []float64{-1.0, 1.0}
+	step := 0.5
+	lower := []float64{-2.0, -2.0}
+	upper := []float64{0.8, 2.0}
+	g := func(x []float64) float64 { return x[0] + x[1] } // x0 + x1 >= 0
+	nm := MakeBoundedNelderMeadOptimizer(Rosenbrock, 2, lower, upper, []func([]float64) float64{g})
+	xval, _, violation, _, _ := nm.OptimizeStep(500, start, step)
+	if violation > tol {
+		t.Errorf("expected a near-feasible result, got %v (violation %v)", xval, violation)
+	}
+	if xval[0] > upper[0]+tol {
+		t.Errorf("expected x[0] <= %v, got %v", upper[0], xval[0])
+	}
+}
+
+func TestMakeBoundedSimplexValues_ShrinksStep(t *testing.T) {
+	start := []float64{0.0, 0.0}
+	lower := []float64{0.0, 0.0}
+	upper := []float64{0.1, 0.1}
+	spx := MakeBoundedSimplexValues(start, 0.5, lower, upper)
+	if spx[1].X[0] == spx[0].X[0] || spx[2].X[1] == spx[0].X[1] {
+		t.Fatalf("expected a non-degenerate simplex shrunk to fit the box, got %v", spx)
+	}
+}
+
+func TestNelderMeadOptimizer_OptimizeBoundedSmallBox(t *testing.T) {
+	// The unconstrained optimum of this paraboloid is [1, 1], well outside
+	// the box, so the constrained optimum sits at the corner [0.1, 0.1].
+	f := func(x []float64) float64 { return (1-x[0])*(1-x[0]) + (1-x[1])*(1-x[1]) }
+	start := []float64{0.0, 0.0}
+	lower := []float64{0.0, 0.0}
+	upper := []float64{0.1, 0.1}
+	nm := MakeBoundedNelderMeadOptimizer(f, 2, lower, upper, nil)
+	xval, _, _, _, _ := nm.OptimizeStep(500, start, 0.5)
+	if xval[0] < 0.05 || xval[1] < 0.05 {
+		t.Errorf("expected the search to move towards the feasible corner [0.1 0.1], got %v", xval)
+	}
+}
+
+func TestNelderMeadOptimizer_OptimizeBoundedInitializers(t *testing.T) {
+	// Pin start to the lower-left corner of a small box so AxisSimplex and
+	// SpendleyRegularSimplex must shrink (not just clip) their vertices to
+	// stay non-degenerate; PfefferSimplex's DeltaZ step already fits the box.
+	f := func(x []float64) float64 { return (1-x[0])*(1-x[0]) + (1-x[1])*(1-x[1]) }
+	start := []float64{0.0, 0.0}
+	lower := []float64{0.0, 0.0}
+	upper := []float64{0.1, 0.1}
+	inits := []SimplexInitializer{
+		AxisSimplex{Step: 0.5},
+		PfefferSimplex{DeltaU: 0.05, DeltaZ: 0.00025},
+		SpendleyRegularSimplex{Edge: 0.5},
+	}
+	for _, init := range inits {
+		nm := MakeBoundedNelderMeadOptimizer(f, 2, lower, upper, nil)
+		xval, _, _, _, _ := nm.Optimize(500, start, init)
+		if xval[0] < 0.05 || xval[1] < 0.05 {
+			t.Errorf("%T: expected the search to move towards the feasible corner [0.1 0.1], got %v", init, xval)
+		}
+	}
+}
+
+func TestNelderMeadOptimizer_OptimizeMuCapped(t *testing.T) {
+	g := func(x []float64) float64 { return -1.0 } // never satisfiable: g(x) >= 0 never holds
+	nm := MakeBoundedNelderMeadOptimizer(Rosenbrock, 2, nil, nil, []func([]float64) float64{g})
+	start := []float64{-1.0, 1.0}
+	xval, _, _, _, _ := nm.OptimizeStep(5000, start, 0.5)
+	if nm.mu > maxMu {
+		t.Errorf("expected mu to be capped at %v, got %v", maxMu, nm.mu)
+	}
+	if s := nm.score(xval); math.IsNaN(s) || math.IsInf(s, 0) {
+		t.Errorf("expected score to stay finite once mu is capped, got %v", s)
+	}
+}